@@ -0,0 +1,117 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/xwb1989/sqlparser"
+)
+
+func makeHeader(names ...string) []*sqlparser.ColumnDefinition {
+	defs := make([]*sqlparser.ColumnDefinition, len(names))
+	for i, n := range names {
+		defs[i] = &sqlparser.ColumnDefinition{Name: sqlparser.NewColIdent(n)}
+	}
+	return defs
+}
+
+func cols(names ...string) sqlparser.Columns {
+	c := make(sqlparser.Columns, len(names))
+	for i, n := range names {
+		c[i] = sqlparser.NewColIdent(n)
+	}
+	return c
+}
+
+func TestColumnPermutation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cols    sqlparser.Columns
+		header  []*sqlparser.ColumnDefinition
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:   "identity",
+			cols:   cols("a", "b", "c"),
+			header: makeHeader("a", "b", "c"),
+			want:   []int{0, 1, 2},
+		},
+		{
+			name:   "reordered",
+			cols:   cols("c", "a", "b"),
+			header: makeHeader("a", "b", "c"),
+			want:   []int{1, 2, 0},
+		},
+		{
+			name:   "case insensitive",
+			cols:   cols("A", "B"),
+			header: makeHeader("a", "b"),
+			want:   []int{0, 1},
+		},
+		{
+			name:   "omitted column filled with NULL",
+			cols:   cols("a", "c"),
+			header: makeHeader("a", "b", "c"),
+			want:   []int{0, -1, 1},
+		},
+		{
+			name:    "unknown column errors",
+			cols:    cols("a", "z"),
+			header:  makeHeader("a", "b"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := columnPermutation(test.cols, test.header)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("[%s] columnPermutation(...) err = nil, want error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] columnPermutation(...) err = %s, want nil", test.name, err)
+			continue
+		}
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("[%s] diff: (-got +want)\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestReorderRow(t *testing.T) {
+	row := sqlparser.ValTuple{
+		sqlparser.NewStrVal([]byte("1a")),
+		sqlparser.NewStrVal([]byte("1b")),
+	}
+
+	got := reorderRow(row, []int{1, -1, 0})
+
+	if len(got) != 3 {
+		t.Fatalf("reorderRow(...) returned %d values, want 3", len(got))
+	}
+	if got[0] != row[1] {
+		t.Errorf("got[0] = %v, want row[1] = %v", got[0], row[1])
+	}
+	if _, ok := got[1].(*sqlparser.NullVal); !ok {
+		t.Errorf("got[1] = %v, want *sqlparser.NullVal", got[1])
+	}
+	if got[2] != row[0] {
+		t.Errorf("got[2] = %v, want row[0] = %v", got[2], row[0])
+	}
+}
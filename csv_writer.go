@@ -28,6 +28,7 @@ import (
 type SQLCsvWriter struct {
 	Comma   string // Field delimiter (defaults ",")
 	Newline string // Line terminator (defaults "\n")
+	Header  bool   // Whether WriteHeader actually prints a header row (defaults true)
 	w       *bufio.Writer
 }
 
@@ -36,12 +37,18 @@ func NewSQLCsvWriter(w io.Writer) *SQLCsvWriter {
 	return &SQLCsvWriter{
 		Comma:   ",",
 		Newline: "\n",
+		Header:  true,
 		w:       bufio.NewWriter(w),
 	}
 }
 
-// WriteHeader writes a header row to the csv.
+// WriteHeader writes a header row to the csv, unless w.Header is false or
+// header is empty (e.g. because no CREATE TABLE was seen for this table).
 func (w *SQLCsvWriter) WriteHeader(header []*sqlparser.ColumnDefinition) error {
+	if !w.Header || len(header) == 0 {
+		return nil
+	}
+
 	for i, c := range header {
 		if _, err := w.w.WriteString(c.Name.String()); err != nil {
 			return err
@@ -85,3 +92,8 @@ func (w *SQLCsvWriter) Write(record []sqlparser.Expr) error {
 func (w *SQLCsvWriter) Flush() error {
 	return w.w.Flush()
 }
+
+// Close is a no-op for csv, which needs no footer or other finalization.
+func (w *SQLCsvWriter) Close() error {
+	return nil
+}
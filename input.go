@@ -0,0 +1,156 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	gzip "github.com/klauspost/pgzip" // (faster than "compress/gzip")
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// openInput opens path (or stdin, if path is "-") and transparently wraps it
+// in a decompressing reader based on its extension. Unknown extensions (and
+// stdin) are sniffed by magic bytes instead, so e.g.
+// `cat dump.sql.zst | mysqldump2csv -` also works.
+func openInput(path string) (io.ReadCloser, error) {
+	var in io.Reader
+	var closer io.Closer
+
+	if path == "-" {
+		in = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		in, closer = f, f
+	}
+
+	buf := bufio.NewReader(in)
+
+	codec := codecFromExt(path)
+	if codec == "" {
+		var err error
+		if codec, err = sniffCodec(buf); err != nil {
+			if closer != nil {
+				closer.Close()
+			}
+			return nil, err
+		}
+	}
+
+	dec, err := decompress(codec, buf)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("Failed to decompress %q as %s: %s", path, codec, err)
+	}
+
+	return &closingReader{Reader: dec, closer: closer}, nil
+}
+
+// closingReader pairs a (possibly decompressing) Reader with the Closer of
+// the underlying file it was opened from, so callers get a single Close.
+type closingReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (c *closingReader) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// codecFromExt returns the codec implied by path's file extension, or ""
+// if it doesn't match a known one (so the caller should sniff instead).
+func codecFromExt(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return "gzip"
+	case strings.HasSuffix(path, ".zst"), strings.HasSuffix(path, ".zstd"):
+		return "zstd"
+	case strings.HasSuffix(path, ".bz2"):
+		return "bzip2"
+	case strings.HasSuffix(path, ".xz"):
+		return "xz"
+	default:
+		return ""
+	}
+}
+
+// Magic bytes identifying each supported codec, used by sniffCodec.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// sniffCodec peeks at the start of buf, without consuming it, to detect one
+// of the supported compression codecs. It returns "" if buf looks like
+// uncompressed SQL.
+func sniffCodec(buf *bufio.Reader) (string, error) {
+	peek, err := buf.Peek(len(xzMagic))
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(peek, gzipMagic):
+		return "gzip", nil
+	case bytes.HasPrefix(peek, zstdMagic):
+		return "zstd", nil
+	case bytes.HasPrefix(peek, bzip2Magic):
+		return "bzip2", nil
+	case bytes.HasPrefix(peek, xzMagic):
+		return "xz", nil
+	default:
+		return "", nil
+	}
+}
+
+// decompress wraps in with the decompressing reader for codec, or returns in
+// unchanged for "" (plain, uncompressed SQL).
+func decompress(codec string, in io.Reader) (io.Reader, error) {
+	switch codec {
+	case "":
+		return in, nil
+	case "gzip":
+		return gzip.NewReader(in)
+	case "zstd":
+		d, err := zstd.NewReader(in)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case "bzip2":
+		return bzip2.NewReader(in), nil
+	case "xz":
+		return xz.NewReader(in)
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", codec)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestCodecFromExt(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"dump.sql", ""},
+		{"dump.sql.gz", "gzip"},
+		{"dump.sql.zst", "zstd"},
+		{"dump.sql.zstd", "zstd"},
+		{"dump.sql.bz2", "bzip2"},
+		{"dump.sql.xz", "xz"},
+		{"-", ""},
+	}
+
+	for _, test := range tests {
+		if got := codecFromExt(test.path); got != test.want {
+			t.Errorf("codecFromExt(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestSniffCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"plain", []byte("INSERT INTO t VALUES (1);\n"), ""},
+		{"empty", []byte(""), ""},
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, "gzip"},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, "zstd"},
+		{"bzip2", []byte("BZh91AY&SY"), "bzip2"},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, "xz"},
+	}
+
+	for _, test := range tests {
+		buf := bufio.NewReader(bytes.NewReader(test.data))
+		got, err := sniffCodec(buf)
+		if err != nil {
+			t.Errorf("[%s] sniffCodec(...) err = %s, want nil", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("[%s] sniffCodec(...) = %q, want %q", test.name, got, test.want)
+		}
+
+		// Peeking must not consume the underlying stream.
+		rest, err := buf.Peek(len(test.data))
+		if err != nil && err != bufio.ErrBufferFull {
+			t.Errorf("[%s] Peek(...) err = %s, want nil", test.name, err)
+		}
+		if !bytes.Equal(rest, test.data) {
+			t.Errorf("[%s] sniffCodec(...) consumed input, got %v, want %v", test.name, rest, test.data)
+		}
+	}
+}
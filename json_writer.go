@@ -0,0 +1,120 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// A JSONWriter writes one JSON object per row, keyed by column name, in the
+// JSON Lines format (one record per line).
+type JSONWriter struct {
+	columns []*sqlparser.ColumnDefinition
+	w       *bufio.Writer
+}
+
+// NewJSONWriter returns a new JSONWriter that writes to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{
+		w: bufio.NewWriter(w),
+	}
+}
+
+// WriteHeader remembers the column names used to key each row's object. jsonl
+// has no header row of its own, so nothing is written here.
+func (w *JSONWriter) WriteHeader(header []*sqlparser.ColumnDefinition) error {
+	w.columns = header
+	return nil
+}
+
+// Write writes a single JSON object, one field per column in record.
+func (w *JSONWriter) Write(record []sqlparser.Expr) error {
+	row := make(map[string]interface{}, len(record))
+	for i, expr := range record {
+		v, err := jsonValue(expr)
+		if err != nil {
+			return err
+		}
+		row[w.columnName(i)] = v
+	}
+
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	_, err = w.w.WriteString("\n")
+	return err
+}
+
+// columnName returns the name of column i, falling back to a positional
+// placeholder when no CREATE TABLE was seen for this table (so w.columns is
+// empty or shorter than the row being written).
+func (w *JSONWriter) columnName(i int) string {
+	if i < len(w.columns) {
+		return w.columns[i].Name.String()
+	}
+	return fmt.Sprintf("col%d", i)
+}
+
+// jsonValue converts a single SQL value into its JSON representation: ints and
+// floats stay numeric, strings are left unquoted (json.Marshal quotes them),
+// NULL becomes nil, and hex/bit values become base64-encoded strings.
+func jsonValue(expr sqlparser.Expr) (interface{}, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.NullVal:
+		return nil, nil
+
+	case *sqlparser.SQLVal:
+		switch expr.Type {
+		case sqlparser.StrVal:
+			return string(expr.Val), nil
+		case sqlparser.IntVal, sqlparser.FloatVal:
+			// json.RawMessage embeds the digits as-is, keeping the number
+			// unquoted without risking float precision loss from parsing it.
+			return json.RawMessage(expr.Val), nil
+		case sqlparser.HexVal:
+			raw, err := hex.DecodeString(string(expr.Val))
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex value %q: %s", expr.Val, err)
+			}
+			return base64.StdEncoding.EncodeToString(raw), nil
+		case sqlparser.BitVal:
+			return base64.StdEncoding.EncodeToString(expr.Val), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported complex expression %q", reflect.TypeOf(expr))
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *JSONWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// Close is a no-op for jsonl, which needs no footer or other finalization.
+func (w *JSONWriter) Close() error {
+	return nil
+}
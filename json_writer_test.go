@@ -0,0 +1,105 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/xwb1989/sqlparser"
+)
+
+func TestJSONWriter(t *testing.T) {
+	header := []*sqlparser.ColumnDefinition{
+		{Name: sqlparser.NewColIdent("string")},
+		{Name: sqlparser.NewColIdent("int")},
+		{Name: sqlparser.NewColIdent("float")},
+		{Name: sqlparser.NewColIdent("hex")},
+		{Name: sqlparser.NewColIdent("bit")},
+		{Name: sqlparser.NewColIdent("null")},
+	}
+	row := []sqlparser.Expr{
+		sqlparser.NewStrVal([]byte("a")),
+		sqlparser.NewIntVal([]byte("1")),
+		sqlparser.NewFloatVal([]byte("2.3")),
+		sqlparser.NewHexVal([]byte("4567")),
+		sqlparser.NewBitVal([]byte("0110")),
+		&sqlparser.NullVal{},
+	}
+
+	var b bytes.Buffer
+	w := NewJSONWriter(&b)
+
+	if err := w.WriteHeader(header); err != nil {
+		t.Fatalf("WriteHeader(...) err = %s, want nil", err)
+	}
+	if err := w.Write(row); err != nil {
+		t.Fatalf("Write(...) err = %s, want nil", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() err = %s, want nil", err)
+	}
+
+	if !strings.HasSuffix(b.String(), "\n") {
+		t.Errorf("Write(...) output %q, want a trailing newline", b.String())
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%q) err = %s, want nil", b.String(), err)
+	}
+
+	want := map[string]interface{}{
+		"string": "a",
+		"int":    float64(1),
+		"float":  2.3,
+		"hex":    "RWc=",
+		"bit":    "MDExMA==",
+		"null":   nil,
+	}
+
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("diff: (-got +want)\n%s", diff)
+	}
+}
+
+// TestJSONWriterUnknownSchema guards against the panic that used to happen
+// when a table's CREATE TABLE was never seen: WriteHeader is never called,
+// so w.columns stays nil and Write must fall back to positional column
+// names instead of indexing out of range.
+func TestJSONWriterUnknownSchema(t *testing.T) {
+	var b bytes.Buffer
+	w := NewJSONWriter(&b)
+
+	row := []sqlparser.Expr{
+		sqlparser.NewIntVal([]byte("1")),
+		sqlparser.NewStrVal([]byte("a")),
+	}
+	if err := w.Write(row); err != nil {
+		t.Fatalf("Write(...) err = %s, want nil", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%q) err = %s, want nil", b.String(), err)
+	}
+
+	want := map[string]interface{}{"col0": float64(1), "col1": "a"}
+	if diff := pretty.Compare(got, want); diff != "" {
+		t.Errorf("diff: (-got +want)\n%s", diff)
+	}
+}
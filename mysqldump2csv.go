@@ -18,17 +18,15 @@ package main
 
 import (
 	"bufio"
-	"errors"
 	"flag"
 	"fmt"
-	gzip "github.com/klauspost/pgzip" // (faster than "compress/gzip")
 	"github.com/xwb1989/sqlparser"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
-	"strings"
+	"runtime"
 )
 
 var (
@@ -39,6 +37,8 @@ var (
 	tableFilter = flag.String("table", "", "filter the results to only this table")
 	header      = flag.Bool("header", true, "print the CSV header")
 	multi       = flag.Bool("multi", false, "a csv file is created for each table")
+	format      = flag.String("format", "csv", "output format: csv, jsonl, or parquet")
+	workers     = flag.Int("workers", runtime.NumCPU(), "in --multi mode, number of tables processed concurrently (also sizes the per-table backpressure buffer)")
 )
 
 // Table holds information about a single Table, and keeps track of writing the output
@@ -46,9 +46,15 @@ var (
 type Table struct {
 	name    string
 	columns []*sqlparser.ColumnDefinition
-	out     io.Writer
-	csv     *SQLCsvWriter
-	count   int
+
+	// hasSchema is true once a CREATE TABLE with a TableSpec has been seen for
+	// this table, meaning columns reflects the real schema rather than being
+	// synthesized from an INSERT's column list.
+	hasSchema bool
+
+	out   io.Writer
+	csv   RowWriter
+	count int
 }
 
 type mySQLDump2Csv struct {
@@ -59,10 +65,17 @@ type mySQLDump2Csv struct {
 	newline     string
 	header      bool
 	tableFilter string
+	format      string
 
 	// For multi output
-	multi bool
-	root  string
+	multi   bool
+	root    string
+	workers int
+
+	// concurrent is set once Process has run its per-table worker pool, so
+	// Close knows each table was already flushed, closed, and logged by its
+	// own worker instead of by the table map below.
+	concurrent bool
 
 	// For single output
 	out io.Writer // Default out
@@ -74,6 +87,8 @@ func newMySQLDump2Csv() *mySQLDump2Csv {
 		delimiter: ",",
 		newline:   "\n",
 		header:    true,
+		format:    "csv",
+		workers:   runtime.NumCPU(),
 		out:       os.Stdout,
 	}
 }
@@ -87,6 +102,9 @@ func (t *Table) Close() error {
 	if err := t.csv.Flush(); err != nil {
 		return err
 	}
+	if err := t.csv.Close(); err != nil {
+		return err
+	}
 
 	if out, ok := t.out.(io.Closer); ok {
 		if err := out.Close(); err != nil {
@@ -108,6 +126,14 @@ func parseArgs() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if *source != "" {
+		if flag.NArg() != 0 {
+			usage()
+			os.Exit(2)
+		}
+		return
+	}
+
 	if flag.NArg() != 1 {
 		usage()
 		os.Exit(2)
@@ -139,6 +165,13 @@ func (app *mySQLDump2Csv) writeRows(t *Table, rows sqlparser.Values) error {
 }
 
 func (app *mySQLDump2Csv) create(s *sqlparser.DDL) error {
+	name := tableName(s.NewName)
+	app.tables[name] = newTableFromDDL(name, s)
+	return nil
+}
+
+// newTableFromDDL builds the Table state for a freshly seen CREATE TABLE.
+func newTableFromDDL(name string, s *sqlparser.DDL) *Table {
 	var columns []*sqlparser.ColumnDefinition
 	if s.TableSpec != nil {
 		columns = s.TableSpec.Columns
@@ -146,18 +179,67 @@ func (app *mySQLDump2Csv) create(s *sqlparser.DDL) error {
 		vlog("Create DDL is missing a TableSpec %q", sqlparser.String(s))
 	}
 
-	name := tableName(s.NewName)
-	app.tables[name] = &Table{
-		name:    name,
-		columns: columns,
+	return &Table{
+		name:      name,
+		columns:   columns,
+		hasSchema: s.TableSpec != nil,
 	}
+}
 
-	return nil
+// columnDefinitionsFromIdents synthesizes column definitions from an INSERT's
+// column list, for tables whose CREATE TABLE was never seen.
+func columnDefinitionsFromIdents(cols sqlparser.Columns) []*sqlparser.ColumnDefinition {
+	defs := make([]*sqlparser.ColumnDefinition, len(cols))
+	for i, c := range cols {
+		defs[i] = &sqlparser.ColumnDefinition{Name: c}
+	}
+	return defs
+}
+
+// columnPermutation returns, for each column in header, the index within cols
+// that supplies its value, or -1 if cols omits that column (to be filled with
+// NULL). It returns an error if cols references a column not present in header.
+func columnPermutation(cols sqlparser.Columns, header []*sqlparser.ColumnDefinition) ([]int, error) {
+	remaining := make(map[string]int, len(cols))
+	for i, c := range cols {
+		remaining[c.Lowered()] = i
+	}
+
+	perm := make([]int, len(header))
+	for i, h := range header {
+		name := h.Name.Lowered()
+		if idx, ok := remaining[name]; ok {
+			perm[i] = idx
+			delete(remaining, name)
+		} else {
+			perm[i] = -1
+		}
+	}
+
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("references unknown columns")
+	}
+
+	return perm, nil
+}
+
+// reorderRow permutes row according to perm, filling any position with no
+// source column (perm[i] == -1) with a NullVal.
+func reorderRow(row sqlparser.ValTuple, perm []int) sqlparser.ValTuple {
+	out := make(sqlparser.ValTuple, len(perm))
+	for i, idx := range perm {
+		if idx == -1 {
+			out[i] = &sqlparser.NullVal{}
+		} else {
+			out[i] = row[idx]
+		}
+	}
+	return out
 }
 
 func (app *mySQLDump2Csv) openCsv(t *Table) error {
 	if app.multi {
-		filename := filepath.Join(app.root, t.name) + ".csv" // TODO(bramp) Ensure t.name is safe for filenames
+		filename := filepath.Join(app.root, t.name) + formatExt(app.format) // TODO(bramp) Ensure t.name is safe for filenames
 		log.Printf("Creating %q for table %q", filename, t.name)
 		out, err := os.Create(filename)
 		if err != nil {
@@ -168,28 +250,25 @@ func (app *mySQLDump2Csv) openCsv(t *Table) error {
 		t.out = app.out
 	}
 
-	t.csv = NewSQLCsvWriter(t.out)
-	t.csv.Comma = app.delimiter
-	t.csv.Newline = app.newline
+	csv, err := newRowWriter(app.format, t.out, app.delimiter, app.newline, app.header)
+	if err != nil {
+		return err
+	}
+	t.csv = csv
 
-	if app.header {
-		if len(t.columns) > 0 {
-			if err := t.csv.WriteHeader(t.columns); err != nil {
-				return err
-			}
-		} else {
-			// TODO If the INSERT's s.Columns is specified use that.
-			log.Printf("Table %q columns are unknown so no header printed.", t.name)
-		}
+	if len(t.columns) == 0 {
+		log.Printf("Table %q columns are unknown so no header printed.", t.name)
 	}
-	return nil
+
+	// WriteHeader is called regardless of app.header: jsonl/parquet use it to
+	// learn the column names/schema rather than to print a header row, and
+	// must see it even when t.columns is empty, so that jsonl falls back to
+	// positional column names and parquet returns an error, instead of
+	// panicking on the first Write.
+	return t.csv.WriteHeader(t.columns)
 }
 
 func (app *mySQLDump2Csv) insert(s *sqlparser.Insert) error {
-	if len(s.Columns) > 0 {
-		return errors.New("insert statement specifies the columns, that is not currently supported")
-	}
-
 	name := tableName(s.Table)
 	if app.tableFilter != "" && app.tableFilter != name {
 		// Ignore this insert
@@ -204,7 +283,7 @@ func (app *mySQLDump2Csv) insert(s *sqlparser.Insert) error {
 			for othername = range app.tables {
 				break
 			}
-			return fmt.Errorf("found INSERT statements for multiple tables %q and %q. Either use --table or --multi", othername, t.name)
+			return fmt.Errorf("found INSERT statements for multiple tables %q and %q. Either use --table or --multi", othername, name)
 		}
 
 		t = &Table{
@@ -213,6 +292,37 @@ func (app *mySQLDump2Csv) insert(s *sqlparser.Insert) error {
 		app.tables[name] = t
 	}
 
+	values, ok := s.Rows.(sqlparser.Values)
+	if !ok {
+		return fmt.Errorf("Unsupported INSERT statement for table %q: %s", t.name, reflect.TypeOf(s.Rows))
+	}
+
+	return app.applyInsert(t, s.Columns, values)
+}
+
+// applyInsert writes a batch of rows to t, synthesizing or permuting against
+// t.columns as necessary when the INSERT specifies an explicit column list.
+// It lazily opens t's csv on the first write. Shared by the serial insert path
+// and the per-table workers used in concurrent --multi mode.
+func (app *mySQLDump2Csv) applyInsert(t *Table, insertColumns sqlparser.Columns, values sqlparser.Values) error {
+	var perm []int
+	if len(insertColumns) > 0 {
+		if !t.hasSchema && t.columns == nil {
+			// No CREATE TABLE seen for this table, so use this INSERT's column
+			// list as the header. Later batches are permuted against it.
+			t.columns = columnDefinitionsFromIdents(insertColumns)
+		}
+
+		p, err := columnPermutation(insertColumns, t.columns)
+		if err != nil {
+			if t.hasSchema {
+				return fmt.Errorf("insert statement for table %q specifies columns not in its CREATE TABLE", t.name)
+			}
+			return fmt.Errorf("insert statement for table %q specifies inconsistent columns across batches, that is not currently supported", t.name)
+		}
+		perm = p
+	}
+
 	// Open the csv on the first attempt to write to it
 	if t.csv == nil {
 		if err := app.openCsv(t); err != nil {
@@ -220,15 +330,30 @@ func (app *mySQLDump2Csv) insert(s *sqlparser.Insert) error {
 		}
 	}
 
-	if values, ok := s.Rows.(sqlparser.Values); ok {
-		return app.writeRows(t, values)
+	if perm != nil {
+		reordered := make(sqlparser.Values, len(values))
+		for i, row := range values {
+			reordered[i] = reorderRow(row, perm)
+		}
+		values = reordered
 	}
 
-	return fmt.Errorf("Unsupported INSERT statement for table %q: %s", t.name, reflect.TypeOf(s.Rows))
+	return app.writeRows(t, values)
 }
 
-// Process reads the supplied stream and outputs csv files.
+// Process reads the supplied stream and outputs csv files. In --multi mode,
+// tables are processed concurrently by a pool of per-table workers; otherwise
+// (single-output mode, where every row shares one writer) it parses and
+// writes serially, exactly as before.
 func (app *mySQLDump2Csv) Process(in io.Reader) error {
+	if app.multi {
+		return app.processConcurrent(in)
+	}
+	return app.processSerial(in)
+}
+
+// processSerial parses and writes every statement on the calling goroutine.
+func (app *mySQLDump2Csv) processSerial(in io.Reader) error {
 	buf := bufio.NewReader(in)
 	tokens := sqlparser.NewTokenizer(buf)
 	tokens.AllowComments = true
@@ -272,6 +397,11 @@ func (app *mySQLDump2Csv) Process(in io.Reader) error {
 
 // Close closes any open csv files.
 func (app *mySQLDump2Csv) Close() error {
+	if app.concurrent {
+		// Each table's worker already flushed, closed, and logged itself.
+		return nil
+	}
+
 	if len(app.tables) == 0 {
 		log.Printf("Found no tables.")
 		return nil
@@ -288,6 +418,11 @@ func (app *mySQLDump2Csv) Close() error {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "binlog" {
+		runBinlog(os.Args[2:])
+		return
+	}
+
 	parseArgs()
 
 	app := newMySQLDump2Csv()
@@ -296,33 +431,30 @@ func main() {
 	app.header = *header
 	app.tableFilter = *tableFilter
 	app.multi = *multi
+	app.format = *format
+	app.workers = *workers
 
-	for _, input := range flag.Args() {
-		var in io.Reader
-		if input == "-" {
-			in = os.Stdin
-		} else {
-			var err error
-
-			in, err = os.Open(input)
-			if err != nil {
-				log.Fatal(err)
-			}
+	if *source != "" {
+		if err := app.processSource(*source, *chunkSize, *where); err != nil {
+			log.Fatal(err)
+		}
+		if err := app.Close(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-			if strings.HasSuffix(input, ".gz") {
-				if in, err = gzip.NewReader(in); err != nil {
-					log.Fatal(err)
-				}
-			}
+	for _, input := range flag.Args() {
+		in, err := openInput(input)
+		if err != nil {
+			log.Fatal(err)
 		}
 
 		if err := app.Process(in); err != nil {
 			log.Fatal(err)
 		}
 
-		if in, ok := in.(io.Closer); ok {
-			in.Close()
-		}
+		in.Close()
 	}
 
 	if err := app.Close(); err != nil {
@@ -107,6 +107,30 @@ func TestMySQLDump2CsvMulti(t *testing.T) {
 	}
 }
 
+// TestMySQLDump2CsvAmbiguousMultiTable guards against a nil-pointer deref in
+// insert()'s ambiguous-multi-table error path: without --table or --multi, a
+// dump with INSERT statements for a second table must return an error
+// instead of panicking while formatting it.
+func TestMySQLDump2CsvAmbiguousMultiTable(t *testing.T) {
+	var b bytes.Buffer
+
+	app := newMySQLDump2Csv()
+	app.out = &b
+
+	sql := `
+INSERT INTO one VALUES (1);
+INSERT INTO two VALUES (2);
+`
+
+	err := app.Process(strings.NewReader(sql))
+	if err == nil {
+		t.Fatal("app.Process(...) err = nil, want an ambiguous multi-table error")
+	}
+	if !strings.Contains(err.Error(), "multiple tables") {
+		t.Errorf("app.Process(...) err = %q, want it to mention multiple tables", err)
+	}
+}
+
 func TestMySQLDump2CsvNotSupported(t *testing.T) {
 	var b bytes.Buffer
 
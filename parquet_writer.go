@@ -0,0 +1,167 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/writer"
+	"github.com/xwb1989/sqlparser"
+)
+
+// A ParquetWriter writes rows out as a single columnar parquet file. Unlike
+// csv/jsonl, parquet needs its schema before the first row is written, so the
+// underlying writer.JSONWriter isn't constructed until WriteHeader.
+type ParquetWriter struct {
+	out     io.Writer
+	columns []*sqlparser.ColumnDefinition
+	pw      *writer.JSONWriter
+}
+
+// NewParquetWriter returns a new ParquetWriter that writes to w.
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{out: w}
+}
+
+// WriteHeader builds the parquet schema from header and opens the underlying
+// column writer. Unlike csv/jsonl, parquet has no way to write a row without
+// first knowing its schema, so header being empty (no CREATE TABLE seen for
+// this table) is an error rather than something to tolerate.
+func (w *ParquetWriter) WriteHeader(header []*sqlparser.ColumnDefinition) error {
+	if len(header) == 0 {
+		return fmt.Errorf("cannot write parquet: no schema known for this table (no CREATE TABLE seen)")
+	}
+
+	w.columns = header
+
+	schema, err := parquetSchema(header)
+	if err != nil {
+		return err
+	}
+
+	pf := writerfile.NewWriterFile(w.out)
+	pw, err := writer.NewJSONWriter(schema, pf, 4)
+	if err != nil {
+		return fmt.Errorf("Failed to create parquet writer: %s", err)
+	}
+
+	w.pw = pw
+	return nil
+}
+
+// Write writes a single row, encoded as the JSON object writer.JSONWriter expects.
+func (w *ParquetWriter) Write(record []sqlparser.Expr) error {
+	if w.pw == nil {
+		return fmt.Errorf("cannot write parquet row: WriteHeader was never called")
+	}
+
+	row := make(map[string]interface{}, len(record))
+	for i, expr := range record {
+		v, err := parquetValue(expr, w.columns[i].Type)
+		if err != nil {
+			return err
+		}
+		row[w.columns[i].Name.String()] = v
+	}
+
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	return w.pw.Write(string(b))
+}
+
+// parquetValue is like jsonValue, except decimal/numeric columns are
+// rendered as their exact textual digits rather than a json.RawMessage
+// number, matching the BYTE_ARRAY/UTF8 physical type parquetPhysicalType
+// falls back to for them: parquet's DECIMAL convertedtype needs an explicit
+// precision/scale-aware fixed-length byte encoding we don't do, so storing
+// the value as a plain string avoids silently truncating or garbling it.
+func parquetValue(expr sqlparser.Expr, t sqlparser.ColumnType) (interface{}, error) {
+	switch strings.ToLower(t.Type) {
+	case "decimal", "numeric":
+		if _, ok := expr.(*sqlparser.NullVal); ok {
+			return nil, nil
+		}
+		return sqlparser.String(expr), nil
+	}
+	return jsonValue(expr)
+}
+
+// Flush writes any buffered row groups to the underlying io.Writer.
+func (w *ParquetWriter) Flush() error {
+	if w.pw == nil {
+		return nil
+	}
+	return w.pw.Flush(true)
+}
+
+// Close writes the parquet footer. It must be called exactly once, after the
+// last row has been written.
+func (w *ParquetWriter) Close() error {
+	if w.pw == nil {
+		return nil
+	}
+	return w.pw.WriteStop()
+}
+
+// parquetSchema builds the JSON schema string expected by
+// github.com/xitongsys/parquet-go/writer.NewJSONWriter, inferring the
+// physical type of each column from its MySQL column type.
+func parquetSchema(columns []*sqlparser.ColumnDefinition) (string, error) {
+	type field struct {
+		Tag string `json:"Tag"`
+	}
+	type schema struct {
+		Tag    string  `json:"Tag"`
+		Fields []field `json:"Fields"`
+	}
+
+	fields := make([]field, len(columns))
+	for i, c := range columns {
+		fields[i] = field{
+			Tag: fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", c.Name.String(), parquetPhysicalType(c.Type)),
+		}
+	}
+
+	b, err := json.Marshal(schema{Tag: "name=parquet_go_root", Fields: fields})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// parquetPhysicalType maps a MySQL column type to the parquet physical type
+// tag used in parquetSchema.
+func parquetPhysicalType(t sqlparser.ColumnType) string {
+	switch strings.ToLower(t.Type) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "year":
+		return "type=INT32"
+	case "bigint":
+		return "type=INT64"
+	case "float", "double":
+		return "type=DOUBLE"
+	case "datetime", "timestamp":
+		return "type=INT96"
+	case "date":
+		return "type=INT32, convertedtype=DATE"
+	default:
+		return "type=BYTE_ARRAY, convertedtype=UTF8"
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+func TestParquetWriter(t *testing.T) {
+	header := []*sqlparser.ColumnDefinition{
+		{Name: sqlparser.NewColIdent("id"), Type: sqlparser.ColumnType{Type: "int"}},
+		{Name: sqlparser.NewColIdent("price"), Type: sqlparser.ColumnType{Type: "decimal"}},
+		{Name: sqlparser.NewColIdent("name"), Type: sqlparser.ColumnType{Type: "varchar"}},
+	}
+	row := []sqlparser.Expr{
+		sqlparser.NewIntVal([]byte("1")),
+		sqlparser.NewFloatVal([]byte("12.34")),
+		sqlparser.NewStrVal([]byte("widget")),
+	}
+
+	var b bytes.Buffer
+	w := NewParquetWriter(&b)
+
+	if err := w.WriteHeader(header); err != nil {
+		t.Fatalf("WriteHeader(...) err = %s, want nil", err)
+	}
+	if err := w.Write(row); err != nil {
+		t.Fatalf("Write(...) err = %s, want nil", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() err = %s, want nil", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() err = %s, want nil", err)
+	}
+
+	if b.Len() == 0 {
+		t.Error("ParquetWriter wrote no bytes")
+	}
+}
+
+// TestParquetWriterNoSchema guards against the nil-pointer deref that used
+// to happen when a table's CREATE TABLE was never seen: parquet cannot
+// write a row without first knowing its schema, so both WriteHeader(nil)
+// and a Write before any successful WriteHeader must return an error
+// instead of panicking.
+func TestParquetWriterNoSchema(t *testing.T) {
+	var b bytes.Buffer
+	w := NewParquetWriter(&b)
+
+	if err := w.WriteHeader(nil); err == nil {
+		t.Error("WriteHeader(nil) err = nil, want error")
+	}
+	if err := w.Write([]sqlparser.Expr{sqlparser.NewIntVal([]byte("1"))}); err == nil {
+		t.Error("Write(...) err = nil, want error since WriteHeader never succeeded")
+	}
+}
+
+func TestParquetValueDecimal(t *testing.T) {
+	decimal := sqlparser.ColumnType{Type: "decimal"}
+
+	v, err := parquetValue(sqlparser.NewFloatVal([]byte("12.34")), decimal)
+	if err != nil {
+		t.Fatalf("parquetValue(...) err = %s, want nil", err)
+	}
+	if v != "12.34" {
+		t.Errorf("parquetValue(...) = %v, want the exact decimal string %q", v, "12.34")
+	}
+
+	v, err = parquetValue(&sqlparser.NullVal{}, decimal)
+	if err != nil {
+		t.Fatalf("parquetValue(...) err = %s, want nil", err)
+	}
+	if v != nil {
+		t.Errorf("parquetValue(NULL, ...) = %v, want nil", v)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// A RowWriter writes a dump (or live query result) out in some output format.
+// Each supported --format has its own implementation.
+type RowWriter interface {
+	// WriteHeader is called once per table, with its columns, before any
+	// rows. Some formats (e.g. jsonl) use this only to remember the column
+	// names rather than emitting a header row.
+	WriteHeader(header []*sqlparser.ColumnDefinition) error
+
+	// Write writes a single row. record has one entry per WriteHeader column.
+	Write(record []sqlparser.Expr) error
+
+	// Flush writes any buffered rows to the underlying io.Writer.
+	Flush() error
+
+	// Close finalizes the format (for example writing a parquet footer). It
+	// does not close the underlying io.Writer; callers remain responsible
+	// for that.
+	Close() error
+}
+
+// formatExt returns the file extension used for format in --multi mode.
+func formatExt(format string) string {
+	switch format {
+	case "jsonl":
+		return ".jsonl"
+	case "parquet":
+		return ".parquet"
+	default:
+		return ".csv"
+	}
+}
+
+// newRowWriter constructs the RowWriter for format, writing to out. comma and
+// newline are only used by the csv format. header controls whether csv
+// prints a header row; other formats decide for themselves whether a header
+// is meaningful and ignore it.
+func newRowWriter(format string, out io.Writer, comma, newline string, header bool) (RowWriter, error) {
+	switch format {
+	case "", "csv":
+		w := NewSQLCsvWriter(out)
+		w.Comma = comma
+		w.Newline = newline
+		w.Header = header
+		return w, nil
+	case "jsonl":
+		return NewJSONWriter(out), nil
+	case "parquet":
+		return NewParquetWriter(out), nil
+	default:
+		return nil, fmt.Errorf("unsupported --format %q", format)
+	}
+}
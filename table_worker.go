@@ -0,0 +1,210 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// tableJob is a unit of work for a single table, pushed by the parsing
+// goroutine and drained by that table's worker. Exactly one of insert or ddl
+// is set.
+type tableJob struct {
+	insert *sqlparser.Insert
+	ddl    *sqlparser.DDL
+}
+
+// tableDispatcher fans parsed statements out to one goroutine per table, so
+// that in --multi mode I/O to N csv files happens in parallel instead of on
+// the parsing goroutine. Every table gets its own goroutine as soon as it's
+// first seen (goroutines are cheap and most sit idle waiting on their
+// channel); app.workers instead bounds how many jobs are actively being
+// worked on at once, via a semaphore acquired per-job rather than for a
+// table's whole lifetime. Holding it for the whole lifetime would deadlock
+// as soon as the table count exceeds app.workers: every slot would be held
+// by a worker blocked reading its (now-idle) channel, so a not-yet-started
+// table's goroutine could never acquire a slot, its channel would fill up,
+// and the parsing goroutine would then block in send() forever.
+// app.workers also sizes each table's job buffer, so a slow disk can't let
+// parsed rows pile up in memory without bound.
+type tableDispatcher struct {
+	app *mySQLDump2Csv
+
+	mu    sync.Mutex
+	chans map[string]chan tableJob
+	sem   chan struct{}
+	wg    sync.WaitGroup
+
+	errOnce sync.Once
+	err     error
+}
+
+func newTableDispatcher(app *mySQLDump2Csv) *tableDispatcher {
+	workers := app.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &tableDispatcher{
+		app:   app,
+		chans: make(map[string]chan tableJob),
+		sem:   make(chan struct{}, workers),
+	}
+}
+
+// send enqueues job for name, starting that table's worker the first time it
+// is seen. It blocks if that table's buffer is full, providing backpressure.
+func (d *tableDispatcher) send(name string, job tableJob) {
+	d.mu.Lock()
+	ch, ok := d.chans[name]
+	if !ok {
+		ch = make(chan tableJob, cap(d.sem))
+		d.chans[name] = ch
+		d.wg.Add(1)
+		go d.run(name, ch)
+	}
+	d.mu.Unlock()
+
+	ch <- job
+}
+
+// run is the dedicated goroutine for a single table: it owns that table's
+// Table/RowWriter and drains ch until the dispatcher closes it. Unlike ch
+// itself, which always exists for the table's lifetime, d.sem is only held
+// for the duration of a single job, so an idle table never starves others.
+func (d *tableDispatcher) run(name string, ch chan tableJob) {
+	defer d.wg.Done()
+
+	if err := d.process(name, ch); err != nil {
+		d.errOnce.Do(func() { d.err = err })
+	}
+}
+
+func (d *tableDispatcher) process(name string, ch chan tableJob) error {
+	t := &Table{name: name}
+
+	for job := range ch {
+		d.sem <- struct{}{}
+		err := d.handleJob(t, name, job)
+		<-d.sem
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := t.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d rows for table %q", t.count, t.name)
+	return nil
+}
+
+// handleJob applies a single job to t, run with d.sem held.
+func (d *tableDispatcher) handleJob(t *Table, name string, job tableJob) error {
+	switch {
+	case job.ddl != nil:
+		nt := newTableFromDDL(name, job.ddl)
+		t.columns = nt.columns
+		t.hasSchema = nt.hasSchema
+		return nil
+
+	case job.insert != nil:
+		values, ok := job.insert.Rows.(sqlparser.Values)
+		if !ok {
+			return fmt.Errorf("Unsupported INSERT statement for table %q: %s", name, reflect.TypeOf(job.insert.Rows))
+		}
+		return d.app.applyInsert(t, job.insert.Columns, values)
+	}
+
+	return nil
+}
+
+// close closes every table's channel, so each worker finishes once it has
+// drained its remaining buffered jobs, then waits for them all to exit.
+func (d *tableDispatcher) close() error {
+	d.mu.Lock()
+	for _, ch := range d.chans {
+		close(ch)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+	return d.err
+}
+
+// failed reports whether any table worker has already errored, so the
+// parsing goroutine can stop early instead of queuing more work.
+func (d *tableDispatcher) failed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err != nil
+}
+
+// processConcurrent parses statements on the calling goroutine and hands each
+// one off to its table's worker, per tableDispatcher.
+func (app *mySQLDump2Csv) processConcurrent(in io.Reader) error {
+	app.concurrent = true
+	d := newTableDispatcher(app)
+
+	buf := bufio.NewReader(in)
+	tokens := sqlparser.NewTokenizer(buf)
+	tokens.AllowComments = true
+
+	for {
+		s, err := sqlparser.ParseNext(tokens)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			vlog("Error parsing sql: %s", err.Error())
+		}
+
+		if s == nil {
+			continue
+		}
+
+		switch s := s.(type) {
+		case *sqlparser.Insert:
+			name := tableName(s.Table)
+			if app.tableFilter != "" && app.tableFilter != name {
+				continue
+			}
+			d.send(name, tableJob{insert: s})
+
+		case *sqlparser.DDL:
+			if s.Action == sqlparser.CreateStr {
+				d.send(tableName(s.NewName), tableJob{ddl: s})
+			} else {
+				vlog("Ignoring %q", sqlparser.String(s))
+			}
+		default:
+			vlog("Ignoring %q", sqlparser.String(s))
+		}
+
+		if d.failed() {
+			break
+		}
+	}
+
+	return d.close()
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProcessConcurrentManyTablesFewWorkers guards against the worker pool
+// deadlocking when the dump has more tables than --workers: every table's
+// goroutine used to hold its semaphore slot for its entire lifetime, so once
+// all slots were taken by workers idling on an empty channel, no further
+// table could ever start.
+func TestProcessConcurrentManyTablesFewWorkers(t *testing.T) {
+	root, err := ioutil.TempDir("", "mysqldump2csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	app := newMySQLDump2Csv()
+	app.multi = true
+	app.root = root
+	app.workers = 1 // fewer workers than the three tables below
+
+	sql := `
+CREATE TABLE one (id int);
+INSERT INTO one VALUES (1);
+CREATE TABLE two (id int);
+INSERT INTO two VALUES (2);
+CREATE TABLE three (id int);
+INSERT INTO three VALUES (3);
+`
+
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Process(strings.NewReader(sql))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("app.Process(...) err = %s, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("app.Process(...) did not return; worker pool deadlocked with more tables than --workers")
+	}
+
+	for _, name := range []string{"one", "two", "three"} {
+		if _, err := os.Stat(filepath.Join(root, name+".csv")); err != nil {
+			t.Errorf("expected %s.csv to exist: %s", name, err)
+		}
+	}
+}